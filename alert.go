@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const alertmanagerAPIPath = "/api/v2/alerts"
+
+// Alert is a single Alertmanager v2 alert, as described by
+// https://github.com/prometheus/alertmanager/blob/main/api/v2/openapi.yaml
+type Alert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt,omitempty"`
+}
+
+// AlertmanagerClient posts alerts to a single Alertmanager instance.
+type AlertmanagerClient struct {
+	url    string
+	client *http.Client
+}
+
+// NewAlertmanagerClient returns a client that posts to the given Alertmanager base URL.
+func NewAlertmanagerClient(url string) *AlertmanagerClient {
+	return &AlertmanagerClient{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Send POSTs the given alerts to Alertmanager's v2 API.
+func (c *AlertmanagerClient) Send(ctx context.Context, alerts []Alert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(alerts)
+	if err != nil {
+		return fmt.Errorf("error encoding alerts: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url+alertmanagerAPIPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error building alertmanager request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending alerts to alertmanager: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("alertmanager returned status %s", resp.Status)
+	}
+	return nil
+}
+
+// AlertThresholds configures when the license-expiry alerts fire.
+type AlertThresholds struct {
+	LicenseWarnDays int
+	LicenseCritDays int
+}
+
+// AlertChecker periodically evaluates the configured targets for alertable
+// conditions (license about to expire, no license, server unreachable) and
+// pushes the result to Alertmanager, with a resolve-on-recovery flow.
+type AlertChecker struct {
+	cfg        *Config
+	client     *AlertmanagerClient
+	thresholds AlertThresholds
+
+	mutex     sync.Mutex
+	active    map[string]string    // alertname+instance -> severity of the currently firing alert
+	downSince map[string]time.Time // instance -> first failed scrape
+}
+
+// NewAlertChecker builds an AlertChecker for the given config and thresholds.
+func NewAlertChecker(cfg *Config, client *AlertmanagerClient, thresholds AlertThresholds) *AlertChecker {
+	return &AlertChecker{
+		cfg:        cfg,
+		client:     client,
+		thresholds: thresholds,
+		active:     make(map[string]string),
+		downSince:  make(map[string]time.Time),
+	}
+}
+
+// Run evaluates all targets once per interval until ctx is cancelled.
+func (a *AlertChecker) Run(ctx context.Context, interval, downAfter time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		a.checkAll(ctx, downAfter)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *AlertChecker) checkAll(ctx context.Context, downAfter time.Duration) {
+	for _, target := range a.cfg.Targets {
+		alerts := a.checkTarget(ctx, target, downAfter)
+		if len(alerts) == 0 {
+			continue
+		}
+		if err := a.client.Send(ctx, alerts); err != nil {
+			logError("Error pushing alerts to alertmanager: %v", err)
+		}
+	}
+}
+
+func (a *AlertChecker) checkTarget(ctx context.Context, target Target, downAfter time.Duration) []Alert {
+	onlyoffice, err := fetchOnlyoffice(ctx, target)
+
+	var alerts []Alert
+	now := time.Now()
+
+	if err != nil {
+		a.mutex.Lock()
+		since, wasDown := a.downSince[target.Name]
+		if !wasDown {
+			a.downSince[target.Name] = now
+		}
+		a.mutex.Unlock()
+
+		if wasDown && now.Sub(since) >= downAfter {
+			alerts = append(alerts, a.fire("OnlyOfficeServerDown", target.Name, "critical",
+				"OnlyOffice server is unreachable",
+				fmt.Sprintf("Scraping %s has been failing for more than %s: %v", target.Name, downAfter, err)))
+		}
+		return alerts
+	}
+
+	a.mutex.Lock()
+	delete(a.downSince, target.Name)
+	a.mutex.Unlock()
+	alerts = append(alerts, a.resolve("OnlyOfficeServerDown", target.Name)...)
+
+	if !onlyoffice.LicenseInfo.HasLicense {
+		alerts = append(alerts, a.fire("OnlyOfficeNoLicense", target.Name, "critical",
+			"OnlyOffice server has no active license",
+			fmt.Sprintf("Target %s reports hasLicense=false", target.Name)))
+	} else {
+		alerts = append(alerts, a.resolve("OnlyOfficeNoLicense", target.Name)...)
+	}
+
+	if end, err := parseOnlyofficeTime(onlyoffice.LicenseInfo.EndDate); err == nil {
+		daysLeft := int(time.Until(end).Hours() / 24)
+		switch {
+		case daysLeft <= a.thresholds.LicenseCritDays:
+			alerts = append(alerts, a.fire("OnlyOfficeLicenseExpiringSoon", target.Name, "critical",
+				"OnlyOffice license is about to expire",
+				fmt.Sprintf("License for %s expires in %d day(s) (%s)", target.Name, daysLeft, end)))
+		case daysLeft <= a.thresholds.LicenseWarnDays:
+			alerts = append(alerts, a.fire("OnlyOfficeLicenseExpiringSoon", target.Name, "warning",
+				"OnlyOffice license is about to expire",
+				fmt.Sprintf("License for %s expires in %d day(s) (%s)", target.Name, daysLeft, end)))
+		default:
+			alerts = append(alerts, a.resolve("OnlyOfficeLicenseExpiringSoon", target.Name)...)
+		}
+	}
+
+	return alerts
+}
+
+// fire records the alert as firing, keyed on alertname+instance, so that a
+// later resolve knows to send a recovery for it. It returns the alert on
+// every call, so a firing alert is re-sent to Alertmanager on each check.
+func (a *AlertChecker) fire(name, instance, severity, summary, description string) Alert {
+	a.mutex.Lock()
+	a.active[name+instance] = severity
+	a.mutex.Unlock()
+
+	return Alert{
+		Labels: map[string]string{
+			"alertname": name,
+			"instance":  instance,
+			"severity":  severity,
+		},
+		Annotations: map[string]string{
+			"summary":     summary,
+			"description": description,
+		},
+		StartsAt: time.Now(),
+	}
+}
+
+// resolve sends a recovery alert (EndsAt in the past) if the alert was
+// previously firing, and clears its de-duplication entry.
+func (a *AlertChecker) resolve(name, instance string) []Alert {
+	key := name + instance
+
+	a.mutex.Lock()
+	severity, wasActive := a.active[key]
+	delete(a.active, key)
+	a.mutex.Unlock()
+
+	if !wasActive {
+		return nil
+	}
+
+	return []Alert{{
+		Labels: map[string]string{
+			"alertname": name,
+			"instance":  instance,
+			"severity":  severity,
+		},
+		StartsAt: time.Now().Add(-time.Minute),
+		EndsAt:   time.Now().Add(-time.Second),
+	}}
+}
+
+// fetchOnlyoffice retrieves a target's info.json, independently of the
+// Exporter's own (cached) Collect path, so the alert checker can run on its
+// own schedule instead of only when Prometheus scrapes /probe.
+func fetchOnlyoffice(ctx context.Context, target Target) (*Onlyoffice, error) {
+	onlyoffice, _, _, err := scrapeTarget(ctx, target)
+	return onlyoffice, err
+}
+
+// parseOnlyofficeTime parses the date formats seen in OnlyOffice's info.json.
+func parseOnlyofficeTime(value string) (time.Time, error) {
+	layouts := []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05.000Z",
+		"2006-01-02",
+	}
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q: %v", value, err)
+}
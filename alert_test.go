@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAlertCheckerFireThenResolve(t *testing.T) {
+	a := NewAlertChecker(&Config{}, nil, AlertThresholds{})
+
+	fired := a.fire("OnlyOfficeNoLicense", "t1", "critical", "summary", "description")
+	if fired.Labels["alertname"] != "OnlyOfficeNoLicense" || fired.Labels["instance"] != "t1" {
+		t.Fatalf("unexpected alert from fire: %+v", fired)
+	}
+
+	resolved := a.resolve("OnlyOfficeNoLicense", "t1")
+	if len(resolved) != 1 {
+		t.Fatalf("expected exactly one resolve alert for a firing alert, got %d", len(resolved))
+	}
+	if resolved[0].EndsAt.IsZero() {
+		t.Fatalf("a resolve alert must set EndsAt")
+	}
+
+	if resolved := a.resolve("OnlyOfficeNoLicense", "t1"); resolved != nil {
+		t.Fatalf("resolving an already-resolved alert should be a no-op, got %+v", resolved)
+	}
+}
+
+// TestAlertCheckerCheckTargetDoesNotDeadlockOnDownPath guards against a
+// regression where checkTarget called fire (which takes a.mutex itself)
+// while still holding a.mutex on the down-target path, deadlocking the
+// single AlertChecker goroutine the first time a server-down alert fired.
+func TestAlertCheckerCheckTargetDoesNotDeadlockOnDownPath(t *testing.T) {
+	a := NewAlertChecker(&Config{}, nil, AlertThresholds{})
+	target := Target{Name: "down-target", URL: "http://127.0.0.1:0"} // nothing listens here
+
+	done := make(chan struct{})
+	go func() {
+		a.checkTarget(context.Background(), target, time.Millisecond)
+		time.Sleep(2 * time.Millisecond)
+		a.checkTarget(context.Background(), target, time.Millisecond) // crosses downAfter, triggers fire
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("checkTarget deadlocked on the down path")
+	}
+}
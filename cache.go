@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// cacheEntry is the last successful scrape result for a target.
+type cacheEntry struct {
+	payload    *Onlyoffice
+	fetchedAt  time.Time
+	statusCode int
+	size       int
+}
+
+// ScrapeCache caches the last successful scrape per target URI and
+// deduplicates concurrent scrapes of the same target via singleflight, so
+// that several Prometheus servers (or a federation endpoint) probing the
+// same instance at once only hit the backend once.
+type ScrapeCache struct {
+	ttl           time.Duration
+	scrapeTimeout time.Duration
+	group         singleflight.Group
+
+	mutex   sync.Mutex
+	entries map[string]cacheEntry
+
+	hits   *prometheus.CounterVec
+	misses *prometheus.CounterVec
+}
+
+// NewScrapeCache returns a cache that treats entries as fresh for ttl. A
+// backend fetch triggered by a cache miss is bounded by scrapeTimeout,
+// independent of any individual caller's own context, since a single fetch
+// may be shared (via singleflight) by several concurrent callers.
+func NewScrapeCache(ttl, scrapeTimeout time.Duration) *ScrapeCache {
+	return &ScrapeCache{
+		ttl:           ttl,
+		scrapeTimeout: scrapeTimeout,
+		entries:       make(map[string]cacheEntry),
+		hits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_hit_total",
+			Help:      "Number of scrapes served from the in-memory cache instead of the backend.",
+		}, []string{"instance"}),
+		misses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_miss_total",
+			Help:      "Number of scrapes that had to hit the OnlyOffice backend.",
+		}, []string{"instance"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *ScrapeCache) Describe(ch chan<- *prometheus.Desc) {
+	c.hits.Describe(ch)
+	c.misses.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *ScrapeCache) Collect(ch chan<- prometheus.Metric) {
+	c.hits.Collect(ch)
+	c.misses.Collect(ch)
+}
+
+// Get returns the freshest known result for target, scraping the backend on
+// a cache miss. If the backend scrape fails, stale cached data is returned
+// (with stale=true) rather than an error, as long as some data is cached.
+func (c *ScrapeCache) Get(ctx context.Context, target Target) (entry cacheEntry, stale bool, err error) {
+	c.mutex.Lock()
+	cached, ok := c.entries[target.URL]
+	fresh := ok && time.Since(cached.fetchedAt) < c.ttl
+	c.mutex.Unlock()
+
+	if fresh {
+		c.hits.WithLabelValues(target.Name).Inc()
+		return cached, false, nil
+	}
+	c.misses.WithLabelValues(target.Name).Inc()
+
+	// The fetch below may be shared across several concurrent callers via
+	// singleflight, and only the first caller's closure actually runs, so it
+	// must not be bound to that caller's own ctx: a cancellation or deadline
+	// specific to them would otherwise wrongly fail every other waiter too.
+	v, err, _ := c.group.Do(target.URL, func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(context.Background(), c.scrapeTimeout)
+		defer cancel()
+
+		payload, statusCode, size, err := scrapeTarget(fetchCtx, target)
+		if err != nil {
+			return cacheEntry{}, err
+		}
+		return cacheEntry{payload: payload, fetchedAt: time.Now(), statusCode: statusCode, size: size}, nil
+	})
+	if err != nil {
+		if ok {
+			return cached, true, nil
+		}
+		return cacheEntry{}, false, err
+	}
+
+	freshEntry := v.(cacheEntry)
+	c.mutex.Lock()
+	c.entries[target.URL] = freshEntry
+	c.mutex.Unlock()
+	return freshEntry, false, nil
+}
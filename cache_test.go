@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScrapeCacheServesStaleDataOnBackendFailure(t *testing.T) {
+	var failing int32
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if atomic.LoadInt32(&failing) != 0 {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	target := Target{Name: "t", URL: srv.URL}
+	cache := NewScrapeCache(20*time.Millisecond, time.Second)
+
+	entry, stale, err := cache.Get(context.Background(), target)
+	if err != nil || stale {
+		t.Fatalf("expected a fresh successful fetch, got stale=%v err=%v", stale, err)
+	}
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Fatalf("expected 1 backend hit, got %d", got)
+	}
+
+	if _, _, err := cache.Get(context.Background(), target); err != nil {
+		t.Fatalf("unexpected error serving from cache: %v", err)
+	}
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Fatalf("expected the fresh cache entry to avoid a second backend hit, got %d hits", got)
+	}
+
+	time.Sleep(30 * time.Millisecond) // let the TTL expire
+	atomic.StoreInt32(&failing, 1)
+
+	entry2, stale2, err := cache.Get(context.Background(), target)
+	if err != nil {
+		t.Fatalf("expected a stale fallback instead of an error, got %v", err)
+	}
+	if !stale2 {
+		t.Fatalf("expected stale=true once the backend starts failing")
+	}
+	if entry2.fetchedAt != entry.fetchedAt {
+		t.Fatalf("expected the stale fallback to return the previously cached entry")
+	}
+}
+
+func TestScrapeCacheReturnsErrorWithNothingCached(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	cache := NewScrapeCache(time.Minute, time.Second)
+	_, stale, err := cache.Get(context.Background(), Target{Name: "t", URL: srv.URL})
+	if err == nil {
+		t.Fatalf("expected an error when there is no cached data to fall back to")
+	}
+	if stale {
+		t.Fatalf("stale should be false when returning a hard error")
+	}
+}
+
+func TestScrapeCacheCoalescesConcurrentMisses(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	cache := NewScrapeCache(time.Minute, time.Second)
+	target := Target{Name: "t", URL: srv.URL}
+
+	const callers = 5
+	var wg sync.WaitGroup
+	wg.Add(callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			defer wg.Done()
+			if _, _, err := cache.Get(context.Background(), target); err != nil {
+				t.Errorf("unexpected error from a coalesced caller: %v", err)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond) // let every goroutine reach group.Do
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Fatalf("expected concurrent misses for the same target to be coalesced into 1 backend request, got %d", got)
+	}
+}
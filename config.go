@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// BasicAuth holds HTTP basic auth credentials for a single target.
+type BasicAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Target describes a single OnlyOffice Document Server to scrape.
+type Target struct {
+	Name        string     `yaml:"name"`
+	URL         string     `yaml:"url"`
+	Insecure    bool       `yaml:"insecure,omitempty"`
+	BearerToken string     `yaml:"bearer_token,omitempty"`
+	BasicAuth   *BasicAuth `yaml:"basic_auth,omitempty"`
+}
+
+// Config is the top-level layout of the exporter's targets configuration file.
+type Config struct {
+	Targets []Target `yaml:"targets"`
+}
+
+// LoadConfig reads and parses the targets configuration file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("error parsing config file: %v", err)
+	}
+	if len(cfg.Targets) == 0 {
+		return nil, fmt.Errorf("config file %s defines no targets", path)
+	}
+	for _, t := range cfg.Targets {
+		if t.Name == "" {
+			return nil, fmt.Errorf("config file %s: target with empty name", path)
+		}
+		if t.URL == "" {
+			return nil, fmt.Errorf("config file %s: target %q has no url", path, t.Name)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// TargetByName returns the target with the given name, if configured.
+func (c *Config) TargetByName(name string) (*Target, bool) {
+	for i := range c.Targets {
+		if c.Targets[i].Name == name {
+			return &c.Targets[i], true
+		}
+	}
+	return nil, false
+}
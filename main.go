@@ -1,18 +1,24 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
+	"time"
 
+	kitlog "github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/prometheus/common/log"
 	"github.com/prometheus/common/version"
+	"github.com/prometheus/exporter-toolkit/web"
 )
 
 const (
@@ -21,29 +27,73 @@ const (
 
 var (
 	listenAddress = flag.String("web.listen-address", ":9876", "Address on which to expose metrics.")
-	metricsPath   = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics.")
-	scrapeURI     = flag.String("scrape_uri", "http://localhost/info/info.json", "URI to the onlyoffice statistics info.")
-	insecure      = flag.Bool("insecure", false, "Ignore onlyoffice server certificate if using https.")
+	metricsPath   = flag.String("web.telemetry-path", "/metrics", "Path under which to expose the exporter's own metrics.")
+	probePath     = flag.String("web.probe-path", "/probe", "Path under which to probe a single target.")
+	configFile    = flag.String("config.file", "onlyoffice-exporter.yml", "Path to the targets configuration file.")
+	webConfigFile = flag.String("web.config.file", "", "Path to a file enabling TLS and/or basic auth on the HTTP server, see https://github.com/prometheus/exporter-toolkit/blob/master/docs/web-configuration.md")
+	scrapeTimeout = flag.Duration("scrape.timeout", 10*time.Second, "Timeout for scraping a single target.")
+	cacheTTL      = flag.Duration("scrape.cache-ttl", 15*time.Second, "How long a scrape result is reused before the backend is hit again.")
+
+	alertmanagerURL      = flag.String("alertmanager.url", "", "Alertmanager URL to push license-expiry and server-down alerts to. Disabled if empty.")
+	alertCheckInterval   = flag.Duration("alert.check-interval", time.Minute, "How often to evaluate targets for alertable conditions.")
+	alertDownAfter       = flag.Duration("alert.down-after", 5*time.Minute, "How long a target must fail to scrape before an OnlyOfficeServerDown alert fires.")
+	alertLicenseWarnDays = flag.Int("alert.license-warn-days", 30, "Days before license expiry at which a warning alert fires.")
+	alertLicenseCritDays = flag.Int("alert.license-crit-days", 7, "Days before license expiry at which a critical alert fires.")
+
+	totalScrapes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "exporter_scrapes_total",
+		Help:      "Total number of probes handled by this exporter, across all targets.",
+	})
+
+	scrapeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "exporter_scrape_failures_total",
+		Help:      "Number of errors while scraping onlyoffice.",
+	}, []string{"instance"})
+
+	scrapeCache *ScrapeCache
+
+	logger = kitlog.NewLogfmtLogger(os.Stdout)
 )
 
+// logInfo, logError and logFatal are thin logfmt wrappers around the
+// package-level logger, kept so call sites read like the printf-style
+// logging the rest of this file otherwise uses.
+func logInfo(format string, args ...interface{}) {
+	logger.Log("level", "info", "msg", fmt.Sprintf(format, args...))
+}
+
+func logError(format string, args ...interface{}) {
+	logger.Log("level", "error", "msg", fmt.Sprintf(format, args...))
+}
+
+func logFatal(format string, args ...interface{}) {
+	logger.Log("level", "error", "msg", fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
 type Exporter struct {
-	URI    string
+	ctx    context.Context
+	target Target
 	mutex  sync.Mutex
-	client *http.Client
 
 	// OnlyOffice metrics.
-	up                       *prometheus.Desc
-	scrapeFailures           prometheus.Counter
-	serverInfo               *prometheus.Desc
-	editConnectionsLastHour  *prometheus.Desc
-	viewConnectionsLastHour  *prometheus.Desc
-	editConnectionsLastDay   *prometheus.Desc
-	viewConnectionsLastDay   *prometheus.Desc
-	editConnectionsLastWeek  *prometheus.Desc
-	viewConnectionsLastWeek  *prometheus.Desc
-	editConnectionsLastMonth *prometheus.Desc
-	viewConnectionsLastMonth *prometheus.Desc
-	licenseInfo              *prometheus.Desc
+	up                   *prometheus.Desc
+	serverInfo           *prometheus.Desc
+	editConnections      *prometheus.Desc
+	viewConnections      *prometheus.Desc
+	licenseInfo          *prometheus.Desc
+	licenseEndTime       *prometheus.Desc
+	licenseBuildTime     *prometheus.Desc
+	lastSuccessfulScrape *prometheus.Desc
+	scrapeStale          *prometheus.Desc
+
+	// Exporter-internal scrape metrics.
+	scrapeDuration  prometheus.Histogram
+	responseSize    prometheus.Gauge
+	httpStatusCode  prometheus.Gauge
+	lastScrapeError prometheus.Gauge
 }
 
 type OnlyofficeStats struct {
@@ -78,165 +128,221 @@ type Onlyoffice struct {
 	} `json:serverInfo`
 }
 
-// NewExporter allocates and initializes metrics
-func NewExporter(uri string) *Exporter {
+// NewExporter allocates and initializes metrics for the given target. ctx is
+// handed to the scrape cache by collect, typically derived from the incoming
+// /probe request with --scrape.timeout applied; a backend fetch on a cache
+// miss runs under the cache's own scrape timeout and is not cancelled with ctx.
+func NewExporter(ctx context.Context, target Target) *Exporter {
 	return &Exporter{
-		URI: uri,
+		ctx:    ctx,
+		target: target,
 		up: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "up"),
 			"Could the OnlyOffice server be reached",
-			nil,
+			[]string{"instance"},
 			nil),
-		scrapeFailures: prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: namespace,
-			Name:      "exporter_scrape_failures_total",
-			Help:      "Number of errors while scraping onlyoffice.",
-		}),
-		editConnectionsLastHour: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "edit_connections_last_hour"),
-			"Number of edit connections during last hour",
-			[]string{"type"},
+		editConnections: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "edit_connections"),
+			"Number of edit connections for the given window and statistic",
+			[]string{"instance", "window", "stat"},
 			nil,
 		),
-		viewConnectionsLastHour: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "view_connections_last_hour"),
-			"Number of view connections during last hour",
-			[]string{"type"},
+		viewConnections: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "view_connections"),
+			"Number of view connections for the given window and statistic",
+			[]string{"instance", "window", "stat"},
 			nil,
 		),
-		editConnectionsLastDay: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "edit_connections_last_day"),
-			"Number of edit connections during last day",
-			[]string{"type"},
-			nil,
-		),
-		viewConnectionsLastDay: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "view_connections_last_day"),
-			"Number of view connections during last day",
-			[]string{"type"},
-			nil,
-		),
-		editConnectionsLastWeek: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "edit_connections_last_week"),
-			"Number of edit connections during last week",
-			[]string{"type"},
+		licenseInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "license_info"),
+			"License Information on OnlyOffice",
+			[]string{"instance", "connections", "has_license", "build_date", "end_date"},
 			nil,
 		),
-		viewConnectionsLastWeek: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "view_connections_last_week"),
-			"Number of view connections during last week",
-			[]string{"type"},
+		licenseEndTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "license_end_timestamp_seconds"),
+			"Unix timestamp at which the OnlyOffice license expires",
+			[]string{"instance"},
 			nil,
 		),
-		editConnectionsLastMonth: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "edit_connections_last_month"),
-			"Number of edit connections during last month",
-			[]string{"type"},
+		licenseBuildTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "license_build_timestamp_seconds"),
+			"Unix timestamp at which the running OnlyOffice license build was generated",
+			[]string{"instance"},
 			nil,
 		),
-		viewConnectionsLastMonth: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "view_connections_last_month"),
-			"Number of view connections during last month",
-			[]string{"type"},
+		lastSuccessfulScrape: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "last_successful_scrape_timestamp_seconds"),
+			"Unix timestamp of the last successful scrape of the OnlyOffice server",
+			[]string{"instance"},
 			nil,
 		),
-		licenseInfo: prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, "", "license_info"),
-			"License Information on OnlyOffice",
-			[]string{"connections", "has_license", "build_date", "end_date"},
+		scrapeStale: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "scrape_stale"),
+			"Whether this scrape served cached data because the backend could not be reached (1) or not (0)",
+			[]string{"instance"},
 			nil,
 		),
 		serverInfo: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "", "server_info"),
 			"Server Information of OnlyOffice",
-			[]string{"build_version", "build_number"},
+			[]string{"instance", "build_version", "build_number"},
 			nil,
 		),
-		client: &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: *insecure},
-			},
-		},
+		scrapeDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "scrape_duration_seconds",
+			Help:      "Time it took to scrape the OnlyOffice server.",
+		}),
+		responseSize: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "response_size_bytes",
+			Help:      "Size of the last scrape response body, in bytes.",
+		}),
+		httpStatusCode: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_status_code",
+			Help:      "HTTP status code of the last scrape.",
+		}),
+		lastScrapeError: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_scrape_error",
+			Help:      "Whether the last scrape of the OnlyOffice server failed (1 for failed, 0 for success).",
+		}),
 	}
 }
 
 // Helper.
-func collectStat(ch chan<- prometheus.Metric, desc *prometheus.Desc, value float64, labelValue string) {
+func collectStat(ch chan<- prometheus.Metric, desc *prometheus.Desc, value float64, labelValues ...string) {
 	ch <- prometheus.MustNewConstMetric(desc,
 		prometheus.GaugeValue,
 		value,
-		labelValue)
+		labelValues...)
 }
 
-// Request metrics to the onlyoffice server via http.
-func (e *Exporter) collect(ch chan<- prometheus.Metric) error {
-	req, err := http.NewRequest("GET", e.URI, nil)
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// scrapeTarget performs the actual HTTP scrape and decodes the OnlyOffice
+// response. It is shared by the exporter's cached collection path and the
+// alert checker, which scrapes independently of Prometheus.
+func scrapeTarget(ctx context.Context, target Target) (*Onlyoffice, int, int, error) {
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: target.Insecure},
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", target.URL, nil)
 	if err != nil {
-		return fmt.Errorf("error building scraping request: %v", err)
+		return nil, 0, 0, fmt.Errorf("error building scraping request: %v", err)
+	}
+	if target.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+target.BearerToken)
+	}
+	if target.BasicAuth != nil {
+		req.SetBasicAuth(target.BasicAuth.Username, target.BasicAuth.Password)
 	}
-	resp, err := e.client.Do(req)
+
+	resp, err := client.Do(req)
 	if err != nil {
-		ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0)
-		return fmt.Errorf("error scraping onlyoffice: %v", err)
+		return nil, 0, 0, fmt.Errorf("error scraping onlyoffice: %v", err)
 	}
-	ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 1)
+	defer resp.Body.Close()
 
 	data, err := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
 	if resp.StatusCode != 200 {
 		if err != nil {
 			data = []byte(err.Error())
 		}
-		return fmt.Errorf("status %s (%d): %s", resp.Status, resp.StatusCode, data)
+		return nil, resp.StatusCode, len(data), fmt.Errorf("status %s (%d): %s", resp.Status, resp.StatusCode, data)
 	}
 
 	var onlyoffice Onlyoffice
-	err = json.Unmarshal([]byte(data), &onlyoffice)
+	if err := json.Unmarshal(data, &onlyoffice); err != nil {
+		return nil, resp.StatusCode, len(data), fmt.Errorf("not a valid json: %v", err)
+	}
+	return &onlyoffice, resp.StatusCode, len(data), nil
+}
+
+// Request metrics to the onlyoffice server via http, going through the
+// shared scrape cache so that concurrent/rapid probes of the same target
+// don't all hit the backend.
+func (e *Exporter) collect(ch chan<- prometheus.Metric) error {
+	instance := e.target.Name
+	start := time.Now()
+	defer func() {
+		e.scrapeDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	entry, stale, err := scrapeCache.Get(e.ctx, e.target)
 	if err != nil {
-		return fmt.Errorf("not a valid json: %v", err)
+		ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, 0, instance)
 		return err
 	}
 
-	collectStat(ch, e.editConnectionsLastHour, float64(onlyoffice.ConnectionsStat.Hour.Edit.Min), "min")
-	collectStat(ch, e.editConnectionsLastHour, float64(onlyoffice.ConnectionsStat.Hour.Edit.Avr), "avr")
-	collectStat(ch, e.editConnectionsLastHour, float64(onlyoffice.ConnectionsStat.Hour.Edit.Max), "max")
-	collectStat(ch, e.viewConnectionsLastHour, float64(onlyoffice.ConnectionsStat.Hour.View.Min), "min")
-	collectStat(ch, e.viewConnectionsLastHour, float64(onlyoffice.ConnectionsStat.Hour.View.Avr), "avr")
-	collectStat(ch, e.viewConnectionsLastHour, float64(onlyoffice.ConnectionsStat.Hour.View.Max), "max")
-
-	collectStat(ch, e.editConnectionsLastDay, float64(onlyoffice.ConnectionsStat.Day.Edit.Min), "min")
-	collectStat(ch, e.editConnectionsLastDay, float64(onlyoffice.ConnectionsStat.Day.Edit.Avr), "avr")
-	collectStat(ch, e.editConnectionsLastDay, float64(onlyoffice.ConnectionsStat.Day.Edit.Max), "max")
-	collectStat(ch, e.viewConnectionsLastDay, float64(onlyoffice.ConnectionsStat.Day.View.Min), "min")
-	collectStat(ch, e.viewConnectionsLastDay, float64(onlyoffice.ConnectionsStat.Day.View.Avr), "avr")
-	collectStat(ch, e.viewConnectionsLastDay, float64(onlyoffice.ConnectionsStat.Day.View.Max), "max")
-
-	collectStat(ch, e.editConnectionsLastWeek, float64(onlyoffice.ConnectionsStat.Week.Edit.Min), "min")
-	collectStat(ch, e.editConnectionsLastWeek, float64(onlyoffice.ConnectionsStat.Week.Edit.Avr), "avr")
-	collectStat(ch, e.editConnectionsLastWeek, float64(onlyoffice.ConnectionsStat.Week.Edit.Max), "max")
-	collectStat(ch, e.viewConnectionsLastWeek, float64(onlyoffice.ConnectionsStat.Week.View.Min), "min")
-	collectStat(ch, e.viewConnectionsLastWeek, float64(onlyoffice.ConnectionsStat.Week.View.Avr), "avr")
-	collectStat(ch, e.viewConnectionsLastWeek, float64(onlyoffice.ConnectionsStat.Week.View.Max), "max")
-
-	collectStat(ch, e.editConnectionsLastMonth, float64(onlyoffice.ConnectionsStat.Month.Edit.Min), "min")
-	collectStat(ch, e.editConnectionsLastMonth, float64(onlyoffice.ConnectionsStat.Month.Edit.Avr), "avr")
-	collectStat(ch, e.editConnectionsLastMonth, float64(onlyoffice.ConnectionsStat.Month.Edit.Max), "max")
-	collectStat(ch, e.viewConnectionsLastMonth, float64(onlyoffice.ConnectionsStat.Month.View.Min), "min")
-	collectStat(ch, e.viewConnectionsLastMonth, float64(onlyoffice.ConnectionsStat.Month.View.Avr), "avr")
-	collectStat(ch, e.viewConnectionsLastMonth, float64(onlyoffice.ConnectionsStat.Month.View.Max), "max")
+	// up must reflect live reachability: a stale cache fallback means the
+	// backend is actually down right now, even though we still have (and
+	// serve) an older successful payload below.
+	up := 1.0
+	if stale {
+		up = 0
+	}
+	ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, up, instance)
+	e.httpStatusCode.Set(float64(entry.statusCode))
+	e.responseSize.Set(float64(entry.size))
+	collectStat(ch, e.lastSuccessfulScrape, float64(entry.fetchedAt.Unix()), instance)
+	collectStat(ch, e.scrapeStale, boolToFloat(stale), instance)
+
+	onlyoffice := entry.payload
+
+	windows := []struct {
+		name  string
+		stats OnlyofficeStats
+	}{
+		{"hour", onlyoffice.ConnectionsStat.Hour},
+		{"day", onlyoffice.ConnectionsStat.Day},
+		{"week", onlyoffice.ConnectionsStat.Week},
+		{"month", onlyoffice.ConnectionsStat.Month},
+	}
+
+	for _, w := range windows {
+		collectStat(ch, e.editConnections, float64(w.stats.Edit.Min), instance, w.name, "min")
+		collectStat(ch, e.editConnections, float64(w.stats.Edit.Avr), instance, w.name, "avr")
+		collectStat(ch, e.editConnections, float64(w.stats.Edit.Max), instance, w.name, "max")
+		collectStat(ch, e.viewConnections, float64(w.stats.View.Min), instance, w.name, "min")
+		collectStat(ch, e.viewConnections, float64(w.stats.View.Avr), instance, w.name, "avr")
+		collectStat(ch, e.viewConnections, float64(w.stats.View.Max), instance, w.name, "max")
+	}
 
 	ch <- prometheus.MustNewConstMetric(e.licenseInfo,
 		prometheus.GaugeValue,
 		1,
+		instance,
 		fmt.Sprint(onlyoffice.LicenseInfo.Connections),
 		fmt.Sprint(onlyoffice.LicenseInfo.HasLicense),
 		onlyoffice.LicenseInfo.BuildDate,
 		onlyoffice.LicenseInfo.EndDate)
 
+	if end, err := parseOnlyofficeTime(onlyoffice.LicenseInfo.EndDate); err == nil {
+		collectStat(ch, e.licenseEndTime, float64(end.Unix()), instance)
+	}
+	if build, err := parseOnlyofficeTime(onlyoffice.LicenseInfo.BuildDate); err == nil {
+		collectStat(ch, e.licenseBuildTime, float64(build.Unix()), instance)
+	}
+
 	ch <- prometheus.MustNewConstMetric(e.serverInfo, prometheus.GaugeValue, 1,
+		instance,
 		onlyoffice.ServerInfo.BuildVersion,
 		fmt.Sprint(onlyoffice.ServerInfo.BuildNumber))
 
+	if stale {
+		return fmt.Errorf("backend unreachable, serving cached data from %s", entry.fetchedAt)
+	}
 	return nil
 }
 
@@ -245,44 +351,121 @@ func (e *Exporter) collect(ch chan<- prometheus.Metric) error {
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	e.mutex.Lock() // To protect metrics from concurrent collects.
 	defer e.mutex.Unlock()
+
 	if err := e.collect(ch); err != nil {
-		log.Errorf("Error scraping onlyoffice: %s", err)
-		e.scrapeFailures.Inc()
-		e.scrapeFailures.Collect(ch)
+		logError("Error scraping onlyoffice: %s", err)
+		e.lastScrapeError.Set(1)
+		scrapeFailures.WithLabelValues(e.target.Name).Inc()
+	} else {
+		e.lastScrapeError.Set(0)
 	}
-	return
+
+	ch <- e.scrapeDuration
+	ch <- e.responseSize
+	ch <- e.httpStatusCode
+	ch <- e.lastScrapeError
 }
 
 // Describe implements Collector.
-// https://github.com/prometheus/client_golang/issues/140
-// NOTE: I must confess that it is still not crystal clear in my mind! :)
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.up
 	ch <- e.serverInfo
-	ch <- e.editConnectionsLastHour
-	ch <- e.editConnectionsLastHour
-	ch <- e.editConnectionsLastDay
-	ch <- e.editConnectionsLastDay
-	ch <- e.editConnectionsLastWeek
-	ch <- e.editConnectionsLastWeek
-	ch <- e.editConnectionsLastMonth
-	ch <- e.editConnectionsLastMonth
+	ch <- e.editConnections
+	ch <- e.viewConnections
 	ch <- e.licenseInfo
+	ch <- e.licenseEndTime
+	ch <- e.licenseBuildTime
+	ch <- e.lastSuccessfulScrape
+	ch <- e.scrapeStale
+	ch <- e.scrapeDuration.Desc()
+	ch <- e.responseSize.Desc()
+	ch <- e.httpStatusCode.Desc()
+	ch <- e.lastScrapeError.Desc()
+}
+
+// probeHandler scrapes a single configured target on demand, in the style of
+// the blackbox/snmp "multi-target exporter" pattern: each probe gets its own
+// registry so that results from one target never leak into another's.
+func probeHandler(w http.ResponseWriter, r *http.Request, cfg *Config) {
+	targetName := r.URL.Query().Get("target")
+	if targetName == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+
+	target, ok := cfg.TargetByName(targetName)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown target %q", targetName), http.StatusNotFound)
+		return
+	}
+	totalScrapes.Inc()
+
+	ctx, cancel := context.WithTimeout(r.Context(), *scrapeTimeout)
+	defer cancel()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewExporter(ctx, *target))
 
-	e.scrapeFailures.Describe(ch)
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
 }
 
 func main() {
 	flag.Parse()
 
-	exporter := NewExporter(*scrapeURI)
-	prometheus.MustRegister(exporter)
+	cfg, err := LoadConfig(*configFile)
+	if err != nil {
+		logFatal("Error loading config file: %v", err)
+	}
+
+	scrapeCache = NewScrapeCache(*cacheTTL, *scrapeTimeout)
+
+	prometheus.MustRegister(totalScrapes)
+	prometheus.MustRegister(scrapeFailures)
+	prometheus.MustRegister(scrapeCache)
+	prometheus.MustRegister(version.NewCollector("onlyoffice_exporter"))
+
+	logInfo("Starting prometheus-onlyoffice-exporter %s", version.Info())
+	logInfo("Build context %s", version.BuildContext())
+	logInfo("Starting Server: %s", *listenAddress)
+	logInfo("Loaded %d target(s) from %s", len(cfg.Targets), *configFile)
+
+	bgCtx, cancelBg := context.WithCancel(context.Background())
+	defer cancelBg()
+
+	if *alertmanagerURL != "" {
+		checker := NewAlertChecker(cfg, NewAlertmanagerClient(*alertmanagerURL), AlertThresholds{
+			LicenseWarnDays: *alertLicenseWarnDays,
+			LicenseCritDays: *alertLicenseCritDays,
+		})
+		logInfo("Alertmanager integration enabled, pushing to %s every %s", *alertmanagerURL, *alertCheckInterval)
+		go checker.Run(bgCtx, *alertCheckInterval, *alertDownAfter)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(*metricsPath, promhttp.Handler())
+	mux.HandleFunc(*probePath, func(w http.ResponseWriter, r *http.Request) {
+		probeHandler(w, r, cfg)
+	})
+	srv := &http.Server{Handler: mux}
 
-	log.Infoln("Starting prometheus-onlyoffice-exporter", version.Info())
-	log.Infoln("Build context", version.BuildContext())
-	log.Infof("Starting Server: %s", *listenAddress)
-	log.Infof("Collect from: %s", *scrapeURI)
+	go func() {
+		if err := web.ListenAndServe(srv, &web.FlagConfig{
+			WebListenAddresses: &[]string{*listenAddress},
+			WebConfigFile:      webConfigFile,
+		}, logger); err != nil && err != http.ErrServerClosed {
+			logFatal("Error starting server: %v", err)
+		}
+	}()
 
-	http.Handle(*metricsPath, promhttp.Handler())
-	log.Fatal(http.ListenAndServe(*listenAddress, nil))
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+	logInfo("Shutting down, waiting for in-flight scrapes to finish...")
+	cancelBg()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logError("Error during graceful shutdown: %v", err)
+	}
 }